@@ -0,0 +1,37 @@
+package agreementbot
+
+// AgreementWorker reports the outcome of agreement negotiation for a node/business-policy pair into
+// the owning PolicyManager's per-node deployment status, so that GetNodePolicyStatus reflects what is
+// actually happening on the fleet instead of whatever was last written directly through
+// SetNodePolicyStatus. One AgreementWorker is created per agbot worker that drives negotiation; all of
+// them report into the same PolicyManager.
+type AgreementWorker struct {
+	pm *PolicyManager
+}
+
+// NewAgreementWorker creates an AgreementWorker that reports negotiation outcomes into pm.
+func NewAgreementWorker(pm *PolicyManager) *AgreementWorker {
+	return &AgreementWorker{pm: pm}
+}
+
+// ProposeAgreement records that terms under org/polName have been proposed to nodeId.
+func (w *AgreementWorker) ProposeAgreement(org string, polName string, nodeId string) error {
+	return w.pm.SetNodePolicyStatus(org, polName, nodeId, STATUS_PROPOSED, "")
+}
+
+// AcceptAgreement records that nodeId has accepted and now has an active agreement under org/polName.
+func (w *AgreementWorker) AcceptAgreement(org string, polName string, nodeId string) error {
+	return w.pm.SetNodePolicyStatus(org, polName, nodeId, STATUS_ACCEPTED, "")
+}
+
+// FailAgreement records that negotiation with nodeId under org/polName failed, with reason as the
+// human readable detail.
+func (w *AgreementWorker) FailAgreement(org string, polName string, nodeId string, reason string) error {
+	return w.pm.SetNodePolicyStatus(org, polName, nodeId, STATUS_FAILED, reason)
+}
+
+// CancelAgreement records that the agreement between nodeId and org/polName was cancelled, with
+// reason as the human readable detail.
+func (w *AgreementWorker) CancelAgreement(org string, polName string, nodeId string, reason string) error {
+	return w.pm.SetNodePolicyStatus(org, polName, nodeId, STATUS_CANCELLED, reason)
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/open-horizon/anax/exchange"
 	"github.com/open-horizon/anax/externalpolicy"
 	"github.com/open-horizon/anax/policy"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"golang.org/x/crypto/sha3"
+	"strings"
 	"sync"
 	"time"
 )
@@ -38,7 +40,13 @@ func (p *ServicePolicyEntry) ShortString() string {
 		p.Updated, p.Hash, p.Policy.Header.Name)
 }
 
-func NewServicePolicyEntry(p *externalpolicy.ExternalPolicy, svcId string) (*ServicePolicyEntry, error) {
+func NewServicePolicyEntry(pm *PolicyManager, p *externalpolicy.ExternalPolicy, svcId string) (*ServicePolicyEntry, error) {
+	if pm != nil {
+		if err := pm.validateProperties(svcId, policyValidationPayload{Properties: p.Properties, Constraints: p.Constraints}); err != nil {
+			return nil, err
+		}
+	}
+
 	pSE := new(ServicePolicyEntry)
 	pSE.Updated = uint64(time.Now().Unix())
 	if hash, err := hashPolicy(p); err != nil {
@@ -55,15 +63,37 @@ func NewServicePolicyEntry(p *externalpolicy.ExternalPolicy, svcId string) (*Ser
 	return pSE, nil
 }
 
+// Deployment states for a node's negotiation of an agreement under a business policy.
+const (
+	STATUS_PROPOSED  = "proposed"  // the agbot has proposed this policy's terms to the node
+	STATUS_ACCEPTED  = "accepted"  // the node has accepted and has an active agreement for this policy
+	STATUS_FAILED    = "failed"    // negotiation failed or the agreement ended in error
+	STATUS_CANCELLED = "cancelled" // the agreement was cancelled by either party
+)
+
+// NodeDeploymentStatus reflects a single node's current negotiation state for a business policy.
+type NodeDeploymentStatus struct {
+	State       string `json:"state"`             // one of the STATUS_* constants
+	LastUpdated uint64 `json:"lastUpdated"`       // the time when this status was last set
+	Message     string `json:"message,omitempty"` // optional human readable detail, e.g. an error reason
+}
+
+func (s *NodeDeploymentStatus) String() string {
+	return fmt.Sprintf("NodeDeploymentStatus: State: %v LastUpdated: %v Message: %v", s.State, s.LastUpdated, s.Message)
+}
+
 type BusinessPolicyEntry struct {
-	Policy          *policy.Policy                 `json:"policy,omitempty"`          // the metadata for this business policy from the exchange, it is the converted to the internal policy format
-	Updated         uint64                         `json:"updatedTime,omitempty"`     // the time when this entry was updated
-	Hash            []byte                         `json:"hash,omitempty"`            // a hash of the business policy to compare for matadata changes in the exchange
-	ServicePolicies map[string]*ServicePolicyEntry `json:"servicePolicies,omitempty"` // map of the service id and service policies
+	Policy          *policy.Policy                   `json:"policy,omitempty"`          // the metadata for this business policy from the exchange, it is the converted to the internal policy format
+	Updated         uint64                           `json:"updatedTime,omitempty"`     // the time when this entry was updated
+	Hash            []byte                           `json:"hash,omitempty"`            // a hash of the business policy to compare for matadata changes in the exchange
+	ServicePolicies map[string]*ServicePolicyEntry   `json:"servicePolicies,omitempty"` // map of the service id and service policies
+	Status          map[string]*NodeDeploymentStatus `json:"status,omitempty"`          // map of node id to that node's deployment status for this policy
+	polId           string                           // the full exchange id (org/polName) this entry was created from, used as the key into PolicyManager.polIdIndex
 }
 
-func NewBusinessPolicyEntry(pol *businesspolicy.BusinessPolicy, polId string) (*BusinessPolicyEntry, error) {
+func NewBusinessPolicyEntry(pm *PolicyManager, pol *businesspolicy.BusinessPolicy, polId string) (*BusinessPolicyEntry, error) {
 	pBE := new(BusinessPolicyEntry)
+	pBE.polId = polId
 	pBE.Updated = uint64(time.Now().Unix())
 	if hash, err := hashPolicy(pol); err != nil {
 		return nil, err
@@ -71,11 +101,17 @@ func NewBusinessPolicyEntry(pol *businesspolicy.BusinessPolicy, polId string) (*
 		pBE.Hash = hash
 	}
 	pBE.ServicePolicies = make(map[string]*ServicePolicyEntry, 0)
+	pBE.Status = make(map[string]*NodeDeploymentStatus, 0)
 
 	// validate and convert the exchange business policy to internal policy format
 	if err := pol.Validate(); err != nil {
 		return nil, fmt.Errorf("Failed to validate the business policy %v. %v", *pol, err)
-	} else if pPolicy, err := pol.GenPolicyFromBusinessPolicy(polId); err != nil {
+	} else if pm != nil {
+		if err := pm.validateProperties(polId, policyValidationPayload{Properties: pol.Properties, Constraints: pol.Constraints}); err != nil {
+			return nil, err
+		}
+	}
+	if pPolicy, err := pol.GenPolicyFromBusinessPolicy(polId); err != nil {
 		return nil, fmt.Errorf("Failed to convert the business policy to internal policy format: %v. %v", *pol, err)
 	} else {
 		pBE.Policy = pPolicy
@@ -117,45 +153,117 @@ func hashPolicy(p interface{}) ([]byte, error) {
 }
 
 // Add a service policy to a BusinessPolicyEntry
-func (p *BusinessPolicyEntry) AddServicePolicy(svcPolicy *externalpolicy.ExternalPolicy, svcId string) error {
+func (p *BusinessPolicyEntry) AddServicePolicy(pm *PolicyManager, svcPolicy *externalpolicy.ExternalPolicy, svcId string) error {
 	if svcPolicy == nil || svcId == "" {
 		return nil
 	}
 
-	pSE, err := NewServicePolicyEntry(svcPolicy, svcId)
+	pSE, err := NewServicePolicyEntry(pm, svcPolicy, svcId)
 	if err != nil {
 		return err
 	}
 	p.ServicePolicies[svcId] = pSE
+
+	if pm != nil {
+		if org, polName, ok := splitPolId(p.polId); ok {
+			pm.persistEntry(org, polName, p)
+		}
+	}
 	return nil
 }
 
+// splitPolId splits a full business policy exchange id (org/polName) into its org and polName parts.
+func splitPolId(polId string) (string, string, bool) {
+	parts := strings.SplitN(polId, "/", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
 func (pe *BusinessPolicyEntry) DeleteAllServicePolicies(org string) {
 	pe.ServicePolicies = make(map[string]*ServicePolicyEntry, 0)
 }
 
-func (p *BusinessPolicyEntry) UpdateEntry(pol *businesspolicy.BusinessPolicy, polId string, newHash []byte) (*policy.Policy, error) {
-	p.Hash = newHash
-	p.Updated = uint64(time.Now().Unix())
-	p.ServicePolicies = make(map[string]*ServicePolicyEntry, 0)
-
-	// validate and convert the exchange business policy to internal policy format
+func (p *BusinessPolicyEntry) UpdateEntry(pm *PolicyManager, pol *businesspolicy.BusinessPolicy, polId string, newHash []byte) (*policy.Policy, error) {
+	// Validate before mutating anything on the entry so that a rejected update leaves the
+	// previously-good entry (and its Hash) completely untouched.
 	if err := pol.Validate(); err != nil {
 		return nil, fmt.Errorf("Failed to validate the business policy %v. %v", *pol, err)
-	} else if pPolicy, err := pol.GenPolicyFromBusinessPolicy(polId); err != nil {
+	}
+	if pm != nil {
+		if err := pm.validateProperties(polId, policyValidationPayload{Properties: pol.Properties, Constraints: pol.Constraints}); err != nil {
+			return nil, err
+		}
+	}
+
+	pPolicy, err := pol.GenPolicyFromBusinessPolicy(polId)
+	if err != nil {
 		return nil, fmt.Errorf("Failed to convert the business policy to internal policy format: %v. %v", *pol, err)
-	} else {
-		p.Policy = pPolicy
-		return pPolicy, nil
 	}
+
+	p.Hash = newHash
+	p.Updated = uint64(time.Now().Unix())
+	p.ServicePolicies = make(map[string]*ServicePolicyEntry, 0)
+	p.Policy = pPolicy
+	return pPolicy, nil
 }
 
 type PolicyManager struct {
 	spMapLock      sync.Mutex                                 // The lock that protects the map of ServedPolicies because it is referenced from another thread.
 	polMapLock     sync.Mutex                                 // The lock that protects the map of BusinessPolicyEntry because it is referenced from another thread.
+	notifyMapLock  sync.Mutex                                 // The lock that protects the map of notification destinations because it is referenced from another thread.
+	schemaMapLock  sync.Mutex                                 // The lock that protects the map of PropertySchemas because it is referenced from another thread.
 	eventChannel   chan events.Message                        // for sending policy change messages
 	ServedPolicies map[string]exchange.ServedBusinessPolicy   // served node org, business policy org and business policy triplets. The key is the triplet exchange id.
 	OrgPolicies    map[string]map[string]*BusinessPolicyEntry // all served policies by this agbot. The first key is org, the second key is business policy exchange id without org.
+
+	// servedOrgsExact and servedOrgPrefixes are derived indices over ServedPolicies, rebuilt in
+	// setServedBusinessPolicies, that let serveOrg/serveBusinessPolicy answer in time independent of
+	// the number of served triplets instead of scanning ServedPolicies on every call.
+	servedOrgsExact   map[string]*orgServeRules
+	servedOrgPrefixes []orgPrefixRule
+
+	// polIdIndex is a reverse index from the full business policy exchange id (org/polName) to its
+	// BusinessPolicyEntry, so UpdatePolicies can find an existing entry in O(1) instead of scanning
+	// OrgPolicies[org] and calling exchange.GetId(polId) per defined policy.
+	polIdIndex map[string]*BusinessPolicyEntry
+
+	// store persists BusinessPolicyEntry state across agbot restarts. May be nil, in which case the
+	// PolicyManager behaves as before: purely in-memory, rebuilt from the exchange on every restart.
+	store PolicyStore
+
+	// persistQueue queues writes against store for runPersistWorker to apply in order, so that
+	// persistEntry/persistDeleteEntry/persistDeleteOrg (called with polMapLock held) never block on
+	// disk I/O themselves, while a save and a later delete for the same key can never race. It is an
+	// unbounded queue rather than a fixed-size channel so that a slow or stuck store call can never
+	// make persistEntry et al. block the polMapLock-holding caller once a fixed buffer filled up.
+	persistQueue *persistQueue
+
+	// NotificationDestinations holds the external webhook sinks that should be notified, in addition to
+	// eventChannel, whenever a policy lifecycle event occurs. The key is the org/polNameGlob combination
+	// that the destination was registered with.
+	NotificationDestinations map[string]*NotificationDestination
+
+	// notifyDedupLock protects notifyDedup.
+	notifyDedupLock sync.Mutex
+
+	// notifyDedup dedups rapid successive notifications of the same hash to the same destination. The
+	// key includes the event type so that, for example, a POLICY_DELETED notification is never dropped
+	// just because a POLICY_CHANGED notification with the same hash was already delivered. Entries are
+	// removed when the destination they were keyed against is deregistered, so this does not grow
+	// without bound over the life of a long-running agbot.
+	notifyDedup map[string][]byte
+
+	// notifyJobs fans notification deliveries out to notifyWorker, so that notifyDestinations (called
+	// with polMapLock held, via UpdatePolicies/deleteOrg/deleteBusinessPolicy) never blocks on network
+	// I/O itself.
+	notifyJobs chan notificationJob
+
+	// PropertySchemas holds compiled JSON schemas used to validate business/service policy properties
+	// on ingest. The key is the full business policy id (org/polName) or the service id the schema was
+	// registered under, or "*" for a schema that applies when no more specific one is registered.
+	PropertySchemas map[string]*jsonschema.Schema
 }
 
 func (pm *PolicyManager) String() string {
@@ -197,12 +305,135 @@ func (pm *PolicyManager) ShortString() string {
 	return res
 }
 
-func NewPolicyManager(eventChannel chan events.Message) *PolicyManager {
+// NewPolicyManager creates a PolicyManager backed by the given PolicyStore. If store is non-nil,
+// its previously persisted state is loaded immediately so that the first SetCurrentBusinessPolicies/
+// UpdatePolicies call compares the freshly fetched exchange state against what was already known,
+// instead of against nothing. This is what keeps a clean restart from emitting a flurry of spurious
+// PolicyChangedMessage events when the exchange hasn't actually changed.
+func NewPolicyManager(eventChannel chan events.Message, store PolicyStore) (*PolicyManager, error) {
 	pm := &PolicyManager{
-		OrgPolicies:  make(map[string]map[string]*BusinessPolicyEntry),
-		eventChannel: eventChannel,
+		OrgPolicies:              make(map[string]map[string]*BusinessPolicyEntry),
+		eventChannel:             eventChannel,
+		NotificationDestinations: make(map[string]*NotificationDestination),
+		notifyDedup:              make(map[string][]byte),
+		notifyJobs:               make(chan notificationJob, 100),
+		persistQueue:             newPersistQueue(),
+		PropertySchemas:          make(map[string]*jsonschema.Schema),
+		polIdIndex:               make(map[string]*BusinessPolicyEntry),
+		store:                    store,
 	}
-	return pm
+
+	if store != nil {
+		loaded, err := store.LoadAll()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to load persisted policy manager state, error %v", err))
+		}
+		for org, orgPolicies := range loaded {
+			pm.OrgPolicies[org] = orgPolicies
+			for _, pe := range orgPolicies {
+				pm.polIdIndex[pe.polId] = pe
+			}
+		}
+
+		dests, err := store.LoadNotificationDestinations()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("unable to load persisted notification destinations, error %v", err))
+		}
+		for _, dest := range dests {
+			pm.NotificationDestinations[notificationKey(dest.Org, dest.PolNameGlob)] = dest
+		}
+	}
+
+	go pm.runNotificationWorker()
+	go pm.runPersistWorker()
+
+	return pm, nil
+}
+
+// RegisterPropertySchema compiles and registers a JSON schema that business/service policy
+// properties and constraints must conform to when ingested under the given key (a full business
+// policy id, org/polName, or a service id). Use "*" as the key to register a schema that applies
+// whenever no more specific schema is registered.
+func (pm *PolicyManager) RegisterPropertySchema(key string, schemaBytes []byte) error {
+	if key == "" {
+		return errors.New("a non-empty key is required to register a property schema")
+	}
+
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(key, bytes.NewReader(schemaBytes)); err != nil {
+		return errors.New(fmt.Sprintf("unable to add property schema resource for %v, error %v", key, err))
+	}
+	schema, err := compiler.Compile(key)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to compile property schema for %v, error %v", key, err))
+	}
+
+	pm.schemaMapLock.Lock()
+	defer pm.schemaMapLock.Unlock()
+	if pm.PropertySchemas == nil {
+		pm.PropertySchemas = make(map[string]*jsonschema.Schema)
+	}
+	pm.PropertySchemas[key] = schema
+	return nil
+}
+
+// policyValidationPayload bundles the property and constraint expressions of a business or service
+// policy into the single document schema validation runs against. Validating this instead of just
+// the properties means validation always runs, rather than the caller skipping it outright because
+// the field it would have validated happened to be nil. Both keys are always present in the
+// document, an unset one as null, so "required" alone never rejects it; a schema rejects an unset
+// field through the type it gives that key (e.g. "type": "array").
+type policyValidationPayload struct {
+	Properties  interface{} `json:"properties"`
+	Constraints interface{} `json:"constraints"`
+}
+
+// validateProperties validates properties (typically a policyValidationPayload) against the schema
+// registered for key, falling back to a "*" default schema if one is registered. It is a no-op if
+// no applicable schema has been registered.
+func (pm *PolicyManager) validateProperties(key string, properties interface{}) error {
+	pm.schemaMapLock.Lock()
+	schema, ok := pm.PropertySchemas[key]
+	if !ok {
+		schema, ok = pm.PropertySchemas["*"]
+	}
+	pm.schemaMapLock.Unlock()
+
+	if !ok || schema == nil {
+		return nil
+	}
+
+	raw, err := json.Marshal(properties)
+	if err != nil {
+		return errors.New(fmt.Sprintf("unable to marshal properties %v for schema validation, error %v", properties, err))
+	}
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return errors.New(fmt.Sprintf("unable to unmarshal properties %v for schema validation, error %v", properties, err))
+	}
+
+	if err := schema.Validate(doc); err != nil {
+		return errors.New(fmt.Sprintf("properties for %v failed schema validation, error %v", key, err))
+	}
+	return nil
+}
+
+// ensureOrgTracked materializes an OrgPolicies map for org if this agbot serves it, either because
+// org exactly matches some served.BusinessPolOrg or because it matches a BusinessPolOrgPrefix rule,
+// and an entry does not already exist. It returns false if this agbot does not serve org at all.
+// This is what lets a concrete org discovered only via a BusinessPolOrgPrefix rule (e.g. "tenant-42"
+// served via prefix "tenant-") actually get ingested: without it, UpdatePolicies would reject such an
+// org with "org not found" forever, since SetCurrentBusinessPolicies has no way to enumerate the
+// concrete orgs a prefix rule will eventually match.
+func (pm *PolicyManager) ensureOrgTracked(org string) bool {
+	if pm.hasOrg(org) {
+		return true
+	}
+	if !pm.serveOrg(org) {
+		return false
+	}
+	pm.OrgPolicies[org] = make(map[string]*BusinessPolicyEntry)
+	return true
 }
 
 func (pm *PolicyManager) hasOrg(org string) bool {
@@ -231,6 +462,55 @@ func (pm *PolicyManager) GetAllBusinessPolicyEntriesForOrg(org string) map[strin
 	return nil
 }
 
+// SetNodePolicyStatus records the given node's current negotiation state for a business policy, for
+// example when the agreement worker proposes, accepts, fails or cancels an agreement under it. It
+// records nothing and returns an error if the policy is not known to this PolicyManager. See
+// AgreementWorker for the producer that
+// feeds this from agreement negotiation outcomes.
+func (pm *PolicyManager) SetNodePolicyStatus(org string, polName string, nodeId string, state string, msg string) error {
+	pm.polMapLock.Lock()
+	defer pm.polMapLock.Unlock()
+
+	if !pm.hasBusinessPolicy(org, polName) {
+		return errors.New(fmt.Sprintf("business policy %v not found in org %v", polName, org))
+	}
+
+	pe := pm.OrgPolicies[org][polName]
+	if pe.Status == nil {
+		pe.Status = make(map[string]*NodeDeploymentStatus, 0)
+	}
+	pe.Status[nodeId] = &NodeDeploymentStatus{
+		State:       state,
+		LastUpdated: uint64(time.Now().Unix()),
+		Message:     msg,
+	}
+	return nil
+}
+
+// GetNodePolicyStatus returns a copy of the per-node deployment status map for the given business
+// policy, or nil if the policy is not known to this PolicyManager. A copy is returned, rather than
+// the live map, so that a caller reading it cannot race with SetNodePolicyStatus mutating the same
+// map under polMapLock after this call has returned.
+func (pm *PolicyManager) GetNodePolicyStatus(org string, polName string) map[string]*NodeDeploymentStatus {
+	pm.polMapLock.Lock()
+	defer pm.polMapLock.Unlock()
+
+	if !pm.hasBusinessPolicy(org, polName) {
+		return nil
+	}
+
+	status := pm.OrgPolicies[org][polName].Status
+	if status == nil {
+		return nil
+	}
+	cp := make(map[string]*NodeDeploymentStatus, len(status))
+	for nodeId, s := range status {
+		sCopy := *s
+		cp[nodeId] = &sCopy
+	}
+	return cp
+}
+
 func (pm *PolicyManager) GetAllPolicyOrgs() []string {
 	pm.polMapLock.Lock()
 	defer pm.polMapLock.Unlock()
@@ -242,41 +522,160 @@ func (pm *PolicyManager) GetAllPolicyOrgs() []string {
 	return orgs
 }
 
-// copy the given map of served business policies
+// orgServeRules is the set of business policy matching rules that apply to an org (or an org
+// prefix) that this agbot serves. It is a derived index, rebuilt from ServedPolicies, so that
+// serveBusinessPolicy/serveOrg do not need to walk every served triplet on every call.
+type orgServeRules struct {
+	wildcard    bool                // true if some served entry for this org uses BusinessPol == "*"
+	polNames    map[string]struct{} // exact business policy names served for this org
+	polPrefixes []string            // BusinessPolPrefix values served for this org
+}
+
+func newOrgServeRules() *orgServeRules {
+	return &orgServeRules{polNames: make(map[string]struct{})}
+}
+
+func (r *orgServeRules) matches(polName string) bool {
+	if r.wildcard {
+		return true
+	}
+	if _, ok := r.polNames[polName]; ok {
+		return true
+	}
+	for _, prefix := range r.polPrefixes {
+		if strings.HasPrefix(polName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *orgServeRules) merge(sp exchange.ServedBusinessPolicy) {
+	if sp.BusinessPol == "*" {
+		r.wildcard = true
+	} else if sp.BusinessPol != "" {
+		r.polNames[sp.BusinessPol] = struct{}{}
+	}
+	if sp.BusinessPolPrefix != "" {
+		r.polPrefixes = append(r.polPrefixes, sp.BusinessPolPrefix)
+	}
+}
+
+// orgPrefixRule pairs a BusinessPolOrgPrefix with the serve rules that apply to any org matching it.
+type orgPrefixRule struct {
+	prefix string
+	rules  *orgServeRules
+}
+
+// findOrCreatePrefixRule returns the orgServeRules for prefix, creating and appending one if this is
+// the first served entry seen for that org prefix.
+func findOrCreatePrefixRule(prefixes *[]orgPrefixRule, prefix string) *orgServeRules {
+	for i := range *prefixes {
+		if (*prefixes)[i].prefix == prefix {
+			return (*prefixes)[i].rules
+		}
+	}
+	rules := newOrgServeRules()
+	*prefixes = append(*prefixes, orgPrefixRule{prefix: prefix, rules: rules})
+	return rules
+}
+
+// copy the given map of served business policies and rebuild the derived serve-rule indices.
 func (pm *PolicyManager) setServedBusinessPolicies(servedPols map[string]exchange.ServedBusinessPolicy) {
 	pm.spMapLock.Lock()
 	defer pm.spMapLock.Unlock()
 
 	// copy the input map
 	pm.ServedPolicies = servedPols
+
+	// Rebuild the indices used by serveOrg/serveBusinessPolicy. This only happens when the served
+	// policy list changes, not once per defined/existing business policy, which is what keeps
+	// UpdatePolicies from scanning ServedPolicies for every policy it looks at.
+	exact := make(map[string]*orgServeRules)
+	prefixes := make([]orgPrefixRule, 0)
+	for _, sp := range pm.ServedPolicies {
+		if sp.BusinessPolOrg != "" {
+			rules, ok := exact[sp.BusinessPolOrg]
+			if !ok {
+				rules = newOrgServeRules()
+				exact[sp.BusinessPolOrg] = rules
+			}
+			rules.merge(sp)
+		}
+		if sp.BusinessPolOrgPrefix != "" {
+			findOrCreatePrefixRule(&prefixes, sp.BusinessPolOrgPrefix).merge(sp)
+		}
+	}
+	pm.servedOrgsExact = exact
+	pm.servedOrgPrefixes = prefixes
+}
+
+// servedOrgMatches returns true if the given served policy entry covers the given policy org,
+// either because it names the org exactly or because the org matches the entry's BusinessPolOrgPrefix.
+func servedOrgMatches(sp exchange.ServedBusinessPolicy, polOrg string) bool {
+	if sp.BusinessPolOrg == polOrg {
+		return true
+	}
+	if sp.BusinessPolOrgPrefix != "" && strings.HasPrefix(polOrg, sp.BusinessPolOrgPrefix) {
+		return true
+	}
+	return false
 }
 
-// chek if the agbot serves the given business policy or not.
+// servedPolicyMatches returns true if the given served policy entry covers the given policy org/name,
+// taking into account the exact name, the "*" wildcard, and the BusinessPolPrefix prefix rule. This
+// mirrors the "rule + rule_prefix" model so an agbot can subscribe to e.g. "foo-*" and pick up any
+// newly-published business policy whose name begins with "foo-".
+func servedPolicyMatches(sp exchange.ServedBusinessPolicy, polOrg string, polName string) bool {
+	if !servedOrgMatches(sp, polOrg) {
+		return false
+	}
+	if sp.BusinessPol == polName || sp.BusinessPol == "*" {
+		return true
+	}
+	if sp.BusinessPolPrefix != "" && strings.HasPrefix(polName, sp.BusinessPolPrefix) {
+		return true
+	}
+	return false
+}
+
+// chek if the agbot serves the given business policy or not. Uses the servedOrgsExact/
+// servedOrgPrefixes indices so the cost does not grow with the number of served triplets.
 func (pm *PolicyManager) serveBusinessPolicy(polOrg string, polName string) bool {
 	pm.spMapLock.Lock()
 	defer pm.spMapLock.Unlock()
 
-	for _, sp := range pm.ServedPolicies {
-		if sp.BusinessPolOrg == polOrg && (sp.BusinessPol == polName || sp.BusinessPol == "*") {
+	if rules, ok := pm.servedOrgsExact[polOrg]; ok && rules.matches(polName) {
+		return true
+	}
+	for _, pr := range pm.servedOrgPrefixes {
+		if strings.HasPrefix(polOrg, pr.prefix) && pr.rules.matches(polName) {
 			return true
 		}
 	}
 	return false
 }
 
-// check if the agbot service the given org or not.
+// check if the agbot service the given org or not. Uses the servedOrgsExact/servedOrgPrefixes
+// indices so the cost does not grow with the number of served triplets.
 func (pm *PolicyManager) serveOrg(polOrg string) bool {
 	pm.spMapLock.Lock()
 	defer pm.spMapLock.Unlock()
 
-	for _, sp := range pm.ServedPolicies {
-		if sp.BusinessPolOrg == polOrg {
+	if _, ok := pm.servedOrgsExact[polOrg]; ok {
+		return true
+	}
+	for _, pr := range pm.servedOrgPrefixes {
+		if strings.HasPrefix(polOrg, pr.prefix) {
 			return true
 		}
 	}
 	return false
 }
 
+// persistEntry, persistDeleteEntry and persistDeleteOrg (which queue writes for runPersistWorker to
+// apply, in order, off this goroutine) are defined in policy_persist_worker.go.
+
 // return an array of node orgs for the given served policy org and policy.
 // this function is called from a different thread.
 func (pm *PolicyManager) GetServedNodeOrgs(polOrg string, polName string) []string {
@@ -285,7 +684,7 @@ func (pm *PolicyManager) GetServedNodeOrgs(polOrg string, polName string) []stri
 
 	node_orgs := []string{}
 	for _, sp := range pm.ServedPolicies {
-		if sp.BusinessPolOrg == polOrg && (sp.BusinessPol == polName || sp.BusinessPol == "*") {
+		if servedPolicyMatches(sp, polOrg, polName) {
 			node_org := sp.NodeOrg
 			// the default node org is the policy org
 			if node_org == "" {
@@ -318,11 +717,13 @@ func (pm *PolicyManager) SetCurrentBusinessPolicies(servedPols map[string]exchan
 	}
 
 	// For each org that this agbot is supposed to be serving, check if it is already in the pm.
-	// If not add to it. The policies will be added later in the UpdatePolicies function.
+	// If not add to it. The policies will be added later in the UpdatePolicies function. Entries with
+	// only a BusinessPolOrgPrefix rule (no exact BusinessPolOrg) have no concrete org to materialize
+	// here; those are picked up lazily by ensureOrgTracked the first time UpdatePolicies is called for
+	// a matching concrete org.
 	for _, served := range servedPols {
-		// If we have encountered a new org in the served policy list, create a map of policies for it.
-		if !pm.hasOrg(served.BusinessPolOrg) {
-			pm.OrgPolicies[served.BusinessPolOrg] = make(map[string]*BusinessPolicyEntry)
+		if served.BusinessPolOrg != "" {
+			pm.ensureOrgTracked(served.BusinessPolOrg)
 		}
 	}
 
@@ -348,8 +749,10 @@ func (pm *PolicyManager) UpdatePolicies(org string, definedPolicies map[string]e
 	pm.polMapLock.Lock()
 	defer pm.polMapLock.Unlock()
 
-	// Exit early on error
-	if !pm.hasOrg(org) {
+	// Exit early on error. ensureOrgTracked also materializes org here if it is only known through a
+	// BusinessPolOrgPrefix rule, so a concrete org discovered via prefix matching is not rejected the
+	// first time this agbot is asked to update its policies.
+	if !pm.ensureOrgTracked(org) {
 		return errors.New(fmt.Sprintf("org %v not found in policy manager", org))
 	}
 
@@ -362,15 +765,13 @@ func (pm *PolicyManager) UpdatePolicies(org string, definedPolicies map[string]e
 	}
 
 	// Delete the business policy from the pm if the policy does not exist on the exchange or the agbot
-	// does not serve it any more.
-	for polName, _ := range pm.OrgPolicies[org] {
+	// does not serve it any more. Membership in definedPolicies is checked through the entry's own
+	// polId rather than rescanning definedPolicies for every existing entry.
+	for polName, pe := range pm.OrgPolicies[org] {
 		need_delete := true
-		if pm.serveBusinessPolicy(org, polName) {
-			for polId, _ := range definedPolicies {
-				if exchange.GetId(polId) == polName {
-					need_delete = false
-					break
-				}
+		if pe != nil && pm.serveBusinessPolicy(org, polName) {
+			if _, exists := definedPolicies[pe.polId]; exists {
+				need_delete = false
 			}
 		}
 
@@ -385,47 +786,58 @@ func (pm *PolicyManager) UpdatePolicies(org string, definedPolicies map[string]e
 	// Now we just need to handle adding new business policies or update existing business policies
 	for polId, exPol := range definedPolicies {
 		pol := exPol.GetBusinessPolicy()
-		if !pm.serveBusinessPolicy(org, exchange.GetId(polId)) {
+		polName := exchange.GetId(polId)
+		if !pm.serveBusinessPolicy(org, polName) {
 			continue
 		}
 
-		need_new_entry := true
-		if pm.hasBusinessPolicy(org, exchange.GetId(polId)) {
-			if pe := pm.OrgPolicies[org][exchange.GetId(polId)]; pe != nil {
-				need_new_entry = false
-
-				// The PolicyEntry is already there, so check if the policy definition has changed.
-				// If the policy has changed, Send a PolicyChangedMessage message. Otherwise the policy
-				// definition we have is current.
-				newHash, err := hashPolicy(&pol)
+		// Find the existing entry, if any, in O(1) via the reverse polId index instead of going
+		// through OrgPolicies[org] keyed by bare policy name.
+		pe := pm.polIdIndex[polId]
+		need_new_entry := pe == nil
+		if pe != nil {
+			// The PolicyEntry is already there, so check if the policy definition has changed.
+			// If the policy has changed, Send a PolicyChangedMessage message. Otherwise the policy
+			// definition we have is current.
+			newHash, err := hashPolicy(&pol)
+			if err != nil {
+				return errors.New(fmt.Sprintf("unable to hash the business policy %v for %v, error %v", pol, org, err))
+			}
+			if !bytes.Equal(pe.Hash, newHash) {
+				// update the cache
+				glog.V(5).Infof("Updating policy entry for %v of org %v because it is changed. ", polId, org)
+				newPol, err := pe.UpdateEntry(pm, &pol, polId, newHash)
 				if err != nil {
-					return errors.New(fmt.Sprintf("unable to hash the business policy %v for %v, error %v", pol, org, err))
+					// The update was rejected (e.g. it failed property schema validation). Leave the
+					// previously-good entry in place and move on to the next defined policy instead of
+					// failing the whole UpdatePolicies pass.
+					glog.Errorf(fmt.Sprintf("error updating business policy entry for %v of org %v: %v", polId, org, err))
+					continue
 				}
-				if !bytes.Equal(pe.Hash, newHash) {
-					// update the cache
-					glog.V(5).Infof("Updating policy entry for %v of org %v because it is changed. ", polId, org)
-					newPol, err := pe.UpdateEntry(&pol, polId, newHash)
-					if err != nil {
-						return errors.New(fmt.Sprintf("error updating business policy entry for %v of org %v: %v", polId, org, err))
-					}
+				pm.persistEntry(org, polName, pe)
 
-					// send a message so that other process can handle it by re-negotiating agreements
-					glog.V(3).Infof(fmt.Sprintf("Policy manager detected changed business policy %v", polId))
-					if policyString, err := policy.MarshalPolicy(newPol); err != nil {
-						glog.Errorf(fmt.Sprintf("Error trying to marshal policy %v error: %v", newPol, err))
-					} else {
-						pm.eventChannel <- events.NewPolicyChangedMessage(events.CHANGED_POLICY, "", newPol.Header.Name, org, policyString)
-					}
+				// send a message so that other process can handle it by re-negotiating agreements
+				glog.V(3).Infof(fmt.Sprintf("Policy manager detected changed business policy %v", polId))
+				if policyString, err := policy.MarshalPolicy(newPol); err != nil {
+					glog.Errorf(fmt.Sprintf("Error trying to marshal policy %v error: %v", newPol, err))
+				} else {
+					pm.eventChannel <- events.NewPolicyChangedMessage(events.CHANGED_POLICY, "", newPol.Header.Name, org, policyString)
+					pm.notifyDestinations(policyChangedEvent, org, newPol.Header.Name, newHash, policyString)
 				}
 			}
 		}
 
 		//If there's no BusinessPolicyEntry yet, create one
 		if need_new_entry {
-			if newPE, err := NewBusinessPolicyEntry(&pol, polId); err != nil {
-				return errors.New(fmt.Sprintf("unable to create business policy entry for %v, error %v", pol, err))
+			if newPE, err := NewBusinessPolicyEntry(pm, &pol, polId); err != nil {
+				// The new policy was rejected (e.g. it failed property schema validation). Skip it and
+				// move on to the next defined policy instead of failing the whole UpdatePolicies pass.
+				glog.Errorf(fmt.Sprintf("unable to create business policy entry for %v, error %v", pol, err))
+				continue
 			} else {
-				pm.OrgPolicies[org][exchange.GetId(polId)] = newPE
+				pm.OrgPolicies[org][polName] = newPE
+				pm.polIdIndex[polId] = newPE
+				pm.persistEntry(org, polName, newPE)
 			}
 		}
 	}
@@ -446,7 +858,9 @@ func (pm *PolicyManager) deleteOrg(org_in string) error {
 						glog.Errorf(fmt.Sprintf("Policy manager error trying to marshal policy %v error: %v", polName, err))
 					} else {
 						pm.eventChannel <- events.NewPolicyDeletedMessage(events.DELETED_POLICY, "", pe.Policy.Header.Name, org, policyString)
+						pm.notifyDestinations(policyDeletedEvent, org, pe.Policy.Header.Name, pe.Hash, policyString)
 					}
+					delete(pm.polIdIndex, pe.polId)
 				}
 			}
 			break
@@ -457,6 +871,12 @@ func (pm *PolicyManager) deleteOrg(org_in string) error {
 	if pm.hasOrg(org_in) {
 		delete(pm.OrgPolicies, org_in)
 	}
+
+	pm.persistDeleteOrg(org_in)
+
+	// The org is gone, so any destinations registered against it should go too.
+	pm.deregisterNotificationDestinationsForOrg(org_in)
+
 	return nil
 }
 
@@ -471,10 +891,14 @@ func (pm *PolicyManager) deleteBusinessPolicy(org string, polName string) error
 					glog.Errorf(fmt.Sprintf("Policy manager error trying to marshal policy %v error: %v", polName, err))
 				} else {
 					pm.eventChannel <- events.NewPolicyDeletedMessage(events.DELETED_POLICY, "", pe.Policy.Header.Name, org, policyString)
+					pm.notifyDestinations(policyDeletedEvent, org, pe.Policy.Header.Name, pe.Hash, policyString)
 				}
+				delete(pm.polIdIndex, pe.polId)
 			}
 
 			delete(pm.OrgPolicies[org], polName)
+
+			pm.persistDeleteEntry(org, polName)
 		}
 	}
 
@@ -0,0 +1,51 @@
+package agreementbot
+
+import (
+	"fmt"
+	"github.com/open-horizon/anax/businesspolicy"
+	"github.com/open-horizon/anax/exchange"
+	"testing"
+)
+
+// benchmarkUpdatePoliciesN defines and serves n business policies within a single org, then times
+// repeatedly reconciling that org's state through UpdatePolicies. Unlike a direct serveBusinessPolicy
+// lookup, this exercises the per-defined-policy loop UpdatePolicies runs on every call -- the
+// polIdIndex lookup and the serveBusinessPolicy check for each of the n policies -- which is exactly
+// the path the removed O(policies x served) scan used to sit on, so this demonstrates that
+// reconciling one org stays cheap as the number of policies defined and served within it grows.
+func benchmarkUpdatePoliciesN(b *testing.B, n int) {
+	org := "bigorg"
+	served := map[string]exchange.ServedBusinessPolicy{
+		org + "/*/" + org: {BusinessPolOrg: org, BusinessPol: "*", NodeOrg: org},
+	}
+
+	defined := make(map[string]exchange.ExchangeBusinessPolicy, n)
+	for i := 0; i < n; i++ {
+		polName := fmt.Sprintf("pol%v", i)
+		defined[org+"/"+polName] = exchange.ExchangeBusinessPolicy{BusinessPolicy: businesspolicy.BusinessPolicy{}}
+	}
+
+	pm := &PolicyManager{
+		OrgPolicies: make(map[string]map[string]*BusinessPolicyEntry),
+		polIdIndex:  make(map[string]*BusinessPolicyEntry),
+	}
+	if err := pm.SetCurrentBusinessPolicies(served); err != nil {
+		b.Fatalf("unexpected error from SetCurrentBusinessPolicies: %v", err)
+	}
+	// Prime the cache so the timed loop exercises the steady-state reconcile path (existing,
+	// unchanged entries) rather than paying one-time entry-creation cost on every iteration.
+	if err := pm.UpdatePolicies(org, defined); err != nil {
+		b.Fatalf("unexpected error from initial UpdatePolicies: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := pm.UpdatePolicies(org, defined); err != nil {
+			b.Fatalf("unexpected error from UpdatePolicies: %v", err)
+		}
+	}
+}
+
+func BenchmarkUpdatePolicies100(b *testing.B)   { benchmarkUpdatePoliciesN(b, 100) }
+func BenchmarkUpdatePolicies1000(b *testing.B)  { benchmarkUpdatePoliciesN(b, 1000) }
+func BenchmarkUpdatePolicies10000(b *testing.B) { benchmarkUpdatePoliciesN(b, 10000) }
@@ -0,0 +1,111 @@
+package agreementbot
+
+import (
+	"github.com/open-horizon/anax/exchange"
+	"testing"
+)
+
+// TestServedPolicyMatchesPrefix verifies the "rule + rule_prefix" matching model: an agbot that is
+// served a BusinessPolPrefix/BusinessPolOrgPrefix rule picks up policies whose name/org begins with
+// that prefix, in addition to the exact-match and "*" wildcard rules.
+func TestServedPolicyMatchesPrefix(t *testing.T) {
+	sp := exchange.ServedBusinessPolicy{
+		BusinessPolOrg:    "myorg",
+		BusinessPolPrefix: "foo-",
+		NodeOrg:           "myorg",
+	}
+
+	if !servedPolicyMatches(sp, "myorg", "foo-bar") {
+		t.Errorf("expected foo-bar in myorg to match BusinessPolPrefix foo-")
+	}
+	if servedPolicyMatches(sp, "myorg", "bar-foo") {
+		t.Errorf("did not expect bar-foo in myorg to match BusinessPolPrefix foo-")
+	}
+	if servedPolicyMatches(sp, "otherorg", "foo-bar") {
+		t.Errorf("did not expect foo-bar in otherorg to match, BusinessPolOrg is myorg")
+	}
+}
+
+// TestServedOrgMatchesPrefix verifies that a BusinessPolOrgPrefix rule covers any org beginning with
+// that prefix, independent of the business policy name rule on the same entry.
+func TestServedOrgMatchesPrefix(t *testing.T) {
+	sp := exchange.ServedBusinessPolicy{
+		BusinessPolOrgPrefix: "tenant-",
+		BusinessPol:          "*",
+	}
+
+	if !servedOrgMatches(sp, "tenant-42") {
+		t.Errorf("expected tenant-42 to match BusinessPolOrgPrefix tenant-")
+	}
+	if servedOrgMatches(sp, "other-42") {
+		t.Errorf("did not expect other-42 to match BusinessPolOrgPrefix tenant-")
+	}
+	if !servedPolicyMatches(sp, "tenant-42", "anything") {
+		t.Errorf("expected the wildcard BusinessPol to match any policy name once the org prefix matches")
+	}
+}
+
+// TestSetCurrentBusinessPoliciesOrgTransitions exercises the org add/remove transitions driven by
+// SetCurrentBusinessPolicies, including org-prefix-served orgs, without needing to construct an
+// actual BusinessPolicyEntry (which depends on packages outside this test's scope).
+func TestSetCurrentBusinessPoliciesOrgTransitions(t *testing.T) {
+	pm := &PolicyManager{
+		OrgPolicies: make(map[string]map[string]*BusinessPolicyEntry),
+		polIdIndex:  make(map[string]*BusinessPolicyEntry),
+	}
+
+	served := map[string]exchange.ServedBusinessPolicy{
+		"myorg/foo-*/myorg": {BusinessPolOrg: "myorg", BusinessPolPrefix: "foo-", NodeOrg: "myorg"},
+	}
+	if err := pm.SetCurrentBusinessPolicies(served); err != nil {
+		t.Fatalf("unexpected error from SetCurrentBusinessPolicies: %v", err)
+	}
+	if !pm.hasOrg("myorg") {
+		t.Fatalf("expected myorg to be tracked after being served")
+	}
+
+	// Stop serving myorg; it should be removed entirely.
+	if err := pm.SetCurrentBusinessPolicies(map[string]exchange.ServedBusinessPolicy{}); err != nil {
+		t.Fatalf("unexpected error from SetCurrentBusinessPolicies: %v", err)
+	}
+	if pm.hasOrg("myorg") {
+		t.Errorf("expected myorg to be removed once no longer served")
+	}
+}
+
+// TestEnsureOrgTrackedMaterializesPrefixMatchedOrg verifies that a concrete org discovered only
+// through a BusinessPolOrgPrefix rule (no exact BusinessPolOrg in ServedPolicies) gets an OrgPolicies
+// entry materialized the first time it is encountered, e.g. by UpdatePolicies, instead of being
+// rejected forever because SetCurrentBusinessPolicies never saw a concrete org name for it.
+func TestEnsureOrgTrackedMaterializesPrefixMatchedOrg(t *testing.T) {
+	pm := &PolicyManager{
+		OrgPolicies: make(map[string]map[string]*BusinessPolicyEntry),
+		polIdIndex:  make(map[string]*BusinessPolicyEntry),
+	}
+
+	served := map[string]exchange.ServedBusinessPolicy{
+		"tenant-*/* /tenant-*": {BusinessPolOrgPrefix: "tenant-", BusinessPol: "*"},
+	}
+	if err := pm.SetCurrentBusinessPolicies(served); err != nil {
+		t.Fatalf("unexpected error from SetCurrentBusinessPolicies: %v", err)
+	}
+
+	// SetCurrentBusinessPolicies alone cannot know the concrete org "tenant-42" exists yet.
+	if pm.hasOrg("tenant-42") {
+		t.Fatalf("did not expect tenant-42 to be materialized before it was ever encountered")
+	}
+
+	if !pm.ensureOrgTracked("tenant-42") {
+		t.Fatalf("expected tenant-42 to be trackable, it matches the BusinessPolOrgPrefix tenant- rule")
+	}
+	if !pm.hasOrg("tenant-42") {
+		t.Errorf("expected tenant-42 to be materialized into OrgPolicies after ensureOrgTracked")
+	}
+
+	if pm.ensureOrgTracked("otherorg") {
+		t.Errorf("did not expect otherorg to be trackable, it matches no served rule")
+	}
+	if pm.hasOrg("otherorg") {
+		t.Errorf("did not expect otherorg to be materialized, it is not served")
+	}
+}
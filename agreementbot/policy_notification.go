@@ -0,0 +1,270 @@
+package agreementbot
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang/glog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// policyNotificationEvent identifies the kind of policy lifecycle event being fanned out to
+// registered notification destinations.
+type policyNotificationEvent string
+
+const (
+	policyChangedEvent policyNotificationEvent = "POLICY_CHANGED"
+	policyDeletedEvent policyNotificationEvent = "POLICY_DELETED"
+)
+
+const (
+	notificationMaxRetries  = 3
+	notificationRetryDelay  = 2 * time.Second
+	notificationHTTPTimeout = 10 * time.Second
+)
+
+// NotificationDestination is an external webhook sink that the PolicyManager will POST policy
+// lifecycle events to, in addition to publishing them on the internal event channel. It is
+// registered for a given org and a glob over business policy names ("*" means all policies in
+// the org), similar in spirit to the agbot's ServedBusinessPolicy rules.
+type NotificationDestination struct {
+	Org         string `json:"org"`
+	PolNameGlob string `json:"polNameGlob"`
+	Url         string `json:"url"`
+	Secret      string `json:"secret,omitempty"` // used to HMAC-sign the payload, empty means unsigned
+}
+
+func (n *NotificationDestination) String() string {
+	return fmt.Sprintf("NotificationDestination: Org: %v PolNameGlob: %v Url: %v", n.Org, n.PolNameGlob, n.Url)
+}
+
+// notificationPayload is the JSON body POSTed to a registered destination.
+type notificationPayload struct {
+	EventType  policyNotificationEvent `json:"eventType"`
+	Org        string                  `json:"org"`
+	PolicyName string                  `json:"policyName"`
+	Hash       string                  `json:"hash"`
+	Policy     string                  `json:"policy"`
+}
+
+// notificationKey builds the key used to store a destination in PolicyManager.NotificationDestinations.
+func notificationKey(org string, polNameGlob string) string {
+	return fmt.Sprintf("%v/%v", org, polNameGlob)
+}
+
+// notificationGlobMatches reports whether polName is covered by polNameGlob, which may be an
+// exact name, "*" for everything in the org, or a "prefix-*" style prefix match.
+func notificationGlobMatches(polNameGlob string, polName string) bool {
+	if polNameGlob == "*" || polNameGlob == polName {
+		return true
+	}
+	if strings.HasSuffix(polNameGlob, "*") {
+		return strings.HasPrefix(polName, strings.TrimSuffix(polNameGlob, "*"))
+	}
+	return false
+}
+
+// RegisterNotificationDestination registers (or replaces) a webhook destination that will receive
+// PolicyChangedMessage/PolicyDeletedMessage events for the given org and business policy name glob.
+func (pm *PolicyManager) RegisterNotificationDestination(org string, polNameGlob string, url string, secret string) error {
+	if org == "" || polNameGlob == "" || url == "" {
+		return errors.New("org, polNameGlob and url are all required to register a notification destination")
+	}
+
+	pm.notifyMapLock.Lock()
+	defer pm.notifyMapLock.Unlock()
+
+	if pm.NotificationDestinations == nil {
+		pm.NotificationDestinations = make(map[string]*NotificationDestination)
+	}
+
+	dest := &NotificationDestination{
+		Org:         org,
+		PolNameGlob: polNameGlob,
+		Url:         url,
+		Secret:      secret,
+	}
+	pm.NotificationDestinations[notificationKey(org, polNameGlob)] = dest
+	pm.persistNotificationDestination(dest)
+	return nil
+}
+
+// DeregisterNotificationDestination removes a previously registered webhook destination.
+func (pm *PolicyManager) DeregisterNotificationDestination(org string, polNameGlob string) {
+	pm.notifyMapLock.Lock()
+	delete(pm.NotificationDestinations, notificationKey(org, polNameGlob))
+	pm.notifyMapLock.Unlock()
+
+	pm.evictNotifyDedup(org, polNameGlob)
+	if pm.store != nil {
+		if err := pm.store.DeleteNotificationDestination(org, polNameGlob); err != nil {
+			glog.Errorf(fmt.Sprintf("Policy manager unable to remove persisted notification destination for %v/%v, error %v", org, polNameGlob, err))
+		}
+	}
+}
+
+// deregisterNotificationDestinationsForOrg removes every destination registered against org, used
+// when the agbot stops serving that org altogether.
+func (pm *PolicyManager) deregisterNotificationDestinationsForOrg(org string) {
+	pm.notifyMapLock.Lock()
+	glomsToRemove := make([]string, 0)
+	for key, dest := range pm.NotificationDestinations {
+		if dest.Org == org {
+			delete(pm.NotificationDestinations, key)
+			glomsToRemove = append(glomsToRemove, dest.PolNameGlob)
+		}
+	}
+	pm.notifyMapLock.Unlock()
+
+	for _, polNameGlob := range glomsToRemove {
+		pm.evictNotifyDedup(org, polNameGlob)
+		if pm.store != nil {
+			if err := pm.store.DeleteNotificationDestination(org, polNameGlob); err != nil {
+				glog.Errorf(fmt.Sprintf("Policy manager unable to remove persisted notification destination for %v/%v, error %v", org, polNameGlob, err))
+			}
+		}
+	}
+}
+
+// persistNotificationDestination saves dest through pm.store, if one is configured.
+func (pm *PolicyManager) persistNotificationDestination(dest *NotificationDestination) {
+	if pm.store == nil {
+		return
+	}
+	if err := pm.store.SaveNotificationDestination(dest); err != nil {
+		glog.Errorf(fmt.Sprintf("Policy manager unable to persist notification destination for %v/%v, error %v", dest.Org, dest.PolNameGlob, err))
+	}
+}
+
+// evictNotifyDedup removes every dedup entry recorded against org/polNameGlob, so that a destination
+// that is deregistered and later re-registered does not have stale hash history suppress its first
+// notification.
+func (pm *PolicyManager) evictNotifyDedup(org string, polNameGlob string) {
+	prefix := notificationKey(org, polNameGlob) + "|"
+
+	pm.notifyDedupLock.Lock()
+	defer pm.notifyDedupLock.Unlock()
+	for key := range pm.notifyDedup {
+		if strings.HasPrefix(key, prefix) {
+			delete(pm.notifyDedup, key)
+		}
+	}
+}
+
+// notificationJob is one queued webhook delivery, consumed by runNotificationWorker.
+type notificationJob struct {
+	dest *NotificationDestination
+	body []byte
+}
+
+// runNotificationWorker is the single long-lived goroutine that drains pm.notifyJobs and delivers
+// each queued notification, so that notifyDestinations (called with polMapLock held) never itself
+// blocks on network I/O.
+func (pm *PolicyManager) runNotificationWorker() {
+	for job := range pm.notifyJobs {
+		deliverNotification(job.dest, job.body)
+	}
+}
+
+// notifyDestinations fans the given policy lifecycle event out to every registered destination whose
+// org and policy name glob match, queuing each delivery for runNotificationWorker to POST with
+// retry/backoff. Identical consecutive notifications (same destination, same event type, same hash)
+// are deduped so a burst of unrelated updates to the same policy does not result in duplicate webhook
+// deliveries; the event type is part of the dedup key so, for example, a POLICY_DELETED notification
+// is never mistaken for a duplicate of an earlier POLICY_CHANGED notification with the same hash.
+func (pm *PolicyManager) notifyDestinations(eventType policyNotificationEvent, org string, polName string, hash []byte, policyString string) {
+	pm.notifyMapLock.Lock()
+	dests := make([]*NotificationDestination, 0)
+	for _, dest := range pm.NotificationDestinations {
+		if dest.Org == org && notificationGlobMatches(dest.PolNameGlob, polName) {
+			dests = append(dests, dest)
+		}
+	}
+	pm.notifyMapLock.Unlock()
+
+	if len(dests) == 0 {
+		return
+	}
+
+	payload := notificationPayload{
+		EventType:  eventType,
+		Org:        org,
+		PolicyName: polName,
+		Hash:       hex.EncodeToString(hash),
+		Policy:     policyString,
+	}
+
+	body, err := json.Marshal(&payload)
+	if err != nil {
+		glog.Errorf(fmt.Sprintf("Policy manager unable to marshal notification payload %v, error %v", payload, err))
+		return
+	}
+
+	for _, dest := range dests {
+		dedupKey := fmt.Sprintf("%v|%v|%v", notificationKey(dest.Org, dest.PolNameGlob), polName, eventType)
+
+		pm.notifyDedupLock.Lock()
+		prior, ok := pm.notifyDedup[dedupKey]
+		if ok && bytes.Equal(prior, hash) {
+			pm.notifyDedupLock.Unlock()
+			glog.V(5).Infof("Policy manager skipping duplicate %v notification to %v for %v, hash unchanged", eventType, dest.Url, polName)
+			continue
+		}
+		pm.notifyDedupLock.Unlock()
+
+		// Only record the dedup entry once the job is actually enqueued. Recording it beforehand
+		// would mark this hash "delivered" even when the queue is full and the job is dropped,
+		// permanently suppressing every future identical notification.
+		select {
+		case pm.notifyJobs <- notificationJob{dest: dest, body: body}:
+			pm.notifyDedupLock.Lock()
+			pm.notifyDedup[dedupKey] = hash
+			pm.notifyDedupLock.Unlock()
+		default:
+			glog.Warningf("Policy manager notification queue full, dropping %v notification to %v for %v", eventType, dest.Url, polName)
+		}
+	}
+}
+
+// deliverNotification POSTs body to dest.Url, optionally HMAC-signing it, retrying on 5xx responses
+// and connection errors with a backoff that grows by notificationRetryDelay after each attempt.
+func deliverNotification(dest *NotificationDestination, body []byte) {
+	client := &http.Client{Timeout: notificationHTTPTimeout}
+
+	var lastErr error
+	for attempt := 0; attempt < notificationMaxRetries; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, dest.Url, bytes.NewReader(body))
+		if err != nil {
+			glog.Errorf(fmt.Sprintf("Policy manager unable to build notification request for %v, error %v", dest.Url, err))
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if dest.Secret != "" {
+			mac := hmac.New(sha256.New, []byte(dest.Secret))
+			mac.Write(body)
+			req.Header.Set("X-Policy-Signature", hex.EncodeToString(mac.Sum(nil)))
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				return
+			}
+			lastErr = errors.New(fmt.Sprintf("destination returned status %v", resp.StatusCode))
+		}
+
+		glog.Warningf("Policy manager notification to %v failed on attempt %v of %v: %v", dest.Url, attempt+1, notificationMaxRetries, lastErr)
+		time.Sleep(notificationRetryDelay * time.Duration(attempt+1))
+	}
+
+	glog.Errorf(fmt.Sprintf("Policy manager giving up on notification to %v after %v attempts, last error %v", dest.Url, notificationMaxRetries, lastErr))
+}
@@ -0,0 +1,93 @@
+package agreementbot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestNotifyDestinationsDedupByEventType verifies that the notification dedup key includes the
+// event type, so a POLICY_DELETED notification is never dropped as a "duplicate" of an earlier
+// POLICY_CHANGED notification carrying the same hash.
+func TestNotifyDestinationsDedupByEventType(t *testing.T) {
+	var deliveries int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&deliveries, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	pm, err := NewPolicyManager(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating policy manager: %v", err)
+	}
+	if err := pm.RegisterNotificationDestination("myorg", "*", srv.URL, ""); err != nil {
+		t.Fatalf("unexpected error registering destination: %v", err)
+	}
+
+	hash := []byte("samehash")
+	pm.notifyDestinations(policyChangedEvent, "myorg", "pol1", hash, "{}")
+	// A second notification with the same hash and event type should be deduped...
+	pm.notifyDestinations(policyChangedEvent, "myorg", "pol1", hash, "{}")
+	// ...but a POLICY_DELETED notification with the same hash must still go through.
+	pm.notifyDestinations(policyDeletedEvent, "myorg", "pol1", hash, "{}")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && atomic.LoadInt32(&deliveries) < 2 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := atomic.LoadInt32(&deliveries); got != 2 {
+		t.Errorf("expected 2 deliveries (1 changed, 1 deleted), got %v", got)
+	}
+}
+
+// TestDeregisterNotificationDestinationsForOrgEvictsDedup verifies that removing a destination (via
+// an org being deleted) evicts its dedup history, so re-registering it later does not have a stale
+// hash suppress its first notification.
+func TestDeregisterNotificationDestinationsForOrgEvictsDedup(t *testing.T) {
+	pm, err := NewPolicyManager(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating policy manager: %v", err)
+	}
+	if err := pm.RegisterNotificationDestination("myorg", "*", "http://example.invalid", ""); err != nil {
+		t.Fatalf("unexpected error registering destination: %v", err)
+	}
+
+	hash := []byte("h1")
+	pm.notifyDestinations(policyChangedEvent, "myorg", "pol1", hash, "{}")
+
+	pm.deregisterNotificationDestinationsForOrg("myorg")
+
+	pm.notifyDedupLock.Lock()
+	defer pm.notifyDedupLock.Unlock()
+	for key := range pm.notifyDedup {
+		t.Errorf("expected dedup state for myorg to be evicted on deregister, found key %v", key)
+	}
+}
+
+// TestNotifyDestinationsDoesNotDedupDroppedJob verifies that a notification dropped because
+// notifyJobs is full is not recorded in the dedup map, so the next identical notification is not
+// silently and permanently suppressed.
+func TestNotifyDestinationsDoesNotDedupDroppedJob(t *testing.T) {
+	pm := &PolicyManager{
+		NotificationDestinations: map[string]*NotificationDestination{
+			notificationKey("myorg", "*"): {Org: "myorg", PolNameGlob: "*", Url: "http://example.invalid"},
+		},
+		notifyDedup: make(map[string][]byte),
+		notifyJobs:  make(chan notificationJob), // unbuffered and undrained: every send blocks, so select hits default
+	}
+
+	hash := []byte("h1")
+	pm.notifyDestinations(policyChangedEvent, "myorg", "pol1", hash, "{}")
+
+	pm.notifyDedupLock.Lock()
+	_, recorded := pm.notifyDedup[fmt.Sprintf("%v|%v|%v", notificationKey("myorg", "*"), "pol1", policyChangedEvent)]
+	pm.notifyDedupLock.Unlock()
+	if recorded {
+		t.Errorf("expected dedup entry to not be recorded for a notification dropped due to a full queue")
+	}
+}
@@ -0,0 +1,143 @@
+package agreementbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"sync"
+)
+
+// persistOp identifies the kind of operation a persistJob asks the persist worker to apply.
+type persistOp int
+
+const (
+	persistSaveEntry persistOp = iota
+	persistDeleteEntry
+	persistDeleteOrg
+)
+
+// persistJob is one queued write against pm.store, consumed in order by runPersistWorker. Queuing
+// these (instead of firing an unsynchronized goroutine per call, as before) guarantees that a save
+// and a later delete for the same org/polName are applied to the store in the same order they were
+// requested, so a rapid update-then-delete can never have its delete race ahead of its save and leave
+// a resurrected entry on disk.
+type persistJob struct {
+	op      persistOp
+	org     string
+	polName string
+	data    []byte // marshaled BusinessPolicyEntry, only set for persistSaveEntry
+}
+
+// persistQueue is an unbounded, order-preserving queue of pending persistence writes. A fixed-size
+// channel would be simpler, but push would then block once the buffer filled -- and every push
+// happens from persistEntry/persistDeleteEntry/persistDeleteOrg, which are called while the caller
+// still holds polMapLock. A slow or stuck store.SaveEntry (a file-locked BoltDB, a stalled disk)
+// would then freeze every other PolicyManager call, not just persistence, for as long as the store
+// stayed stuck. push here only ever holds the queue's own lock, never the store, so it returns
+// immediately regardless of how long the worker's current job is taking.
+type persistQueue struct {
+	lock sync.Mutex
+	cond *sync.Cond
+	jobs []persistJob
+}
+
+func newPersistQueue() *persistQueue {
+	q := &persistQueue{}
+	q.cond = sync.NewCond(&q.lock)
+	return q
+}
+
+// push appends job to the queue and wakes the worker. Never blocks on the worker's progress.
+func (q *persistQueue) push(job persistJob) {
+	q.lock.Lock()
+	q.jobs = append(q.jobs, job)
+	q.lock.Unlock()
+	q.cond.Signal()
+}
+
+// pop blocks until a job is available, then returns it.
+func (q *persistQueue) pop() persistJob {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.jobs) == 0 {
+		q.cond.Wait()
+	}
+	job := q.jobs[0]
+	q.jobs = q.jobs[1:]
+	return job
+}
+
+// empty reports whether the queue currently has no pending jobs.
+func (q *persistQueue) empty() bool {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.jobs) == 0
+}
+
+// runPersistWorker is the single long-lived goroutine that drains pm.persistQueue and applies each
+// queued write to pm.store, so that callers holding polMapLock never stall on disk I/O themselves,
+// while still guaranteeing in-order application per key.
+func (pm *PolicyManager) runPersistWorker() {
+	for {
+		job := pm.persistQueue.pop()
+		switch job.op {
+		case persistSaveEntry:
+			snapshot := new(BusinessPolicyEntry)
+			if err := json.Unmarshal(job.data, snapshot); err != nil {
+				glog.Errorf(fmt.Sprintf("Policy manager unable to unmarshal snapshot of business policy entry for %v of org %v, error %v", job.polName, job.org, err))
+				continue
+			}
+			snapshot.polId = job.org + "/" + job.polName
+			if err := pm.store.SaveEntry(job.org, job.polName, snapshot); err != nil {
+				glog.Errorf(fmt.Sprintf("Policy manager unable to persist business policy entry for %v of org %v, error %v", job.polName, job.org, err))
+			}
+		case persistDeleteEntry:
+			if err := pm.store.DeleteEntry(job.org, job.polName); err != nil {
+				glog.Errorf(fmt.Sprintf("Policy manager unable to remove persisted state for %v of org %v, error %v", job.polName, job.org, err))
+			}
+		case persistDeleteOrg:
+			if err := pm.store.DeleteOrg(job.org); err != nil {
+				glog.Errorf(fmt.Sprintf("Policy manager unable to remove persisted state for org %v, error %v", job.org, err))
+			}
+		}
+	}
+}
+
+// persistEntry saves pe through pm.store, if one is configured, by queuing the write for
+// runPersistWorker. pe is snapshotted via a JSON round trip before queuing so the write always
+// reflects the state of pe at the time persistEntry was called, not whatever it has been mutated to
+// by the time the worker actually processes it. Errors are logged rather than returned because a
+// persistence failure should not prevent the in-memory PolicyManager state (which is still
+// authoritative for this agbot's current run) from being updated.
+func (pm *PolicyManager) persistEntry(org string, polName string, pe *BusinessPolicyEntry) {
+	if pm.store == nil {
+		return
+	}
+
+	data, err := json.Marshal(pe)
+	if err != nil {
+		glog.Errorf(fmt.Sprintf("Policy manager unable to snapshot business policy entry for %v of org %v, error %v", polName, org, err))
+		return
+	}
+
+	pm.persistQueue.push(persistJob{op: persistSaveEntry, org: org, polName: polName, data: data})
+}
+
+// persistDeleteEntry removes the persisted entry for org/polName through pm.store, if one is
+// configured, by queuing the delete for runPersistWorker so it applies after any earlier-queued save
+// for the same key.
+func (pm *PolicyManager) persistDeleteEntry(org string, polName string) {
+	if pm.store == nil {
+		return
+	}
+	pm.persistQueue.push(persistJob{op: persistDeleteEntry, org: org, polName: polName})
+}
+
+// persistDeleteOrg removes every persisted entry for org through pm.store, if one is configured, by
+// queuing the delete for runPersistWorker so it applies after any earlier-queued save for that org.
+func (pm *PolicyManager) persistDeleteOrg(org string) {
+	if pm.store == nil {
+		return
+	}
+	pm.persistQueue.push(persistJob{op: persistDeleteOrg, org: org})
+}
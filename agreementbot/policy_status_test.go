@@ -0,0 +1,99 @@
+package agreementbot
+
+import (
+	"testing"
+)
+
+// TestSetGetNodePolicyStatus verifies basic set/get round-tripping of per-node deployment status,
+// and that GetNodePolicyStatus returns a snapshot rather than the live map.
+func TestSetGetNodePolicyStatus(t *testing.T) {
+	pm := &PolicyManager{
+		OrgPolicies: map[string]map[string]*BusinessPolicyEntry{
+			"myorg": {
+				"pol1": {Status: make(map[string]*NodeDeploymentStatus)},
+			},
+		},
+	}
+
+	if err := pm.SetNodePolicyStatus("myorg", "pol1", "node1", STATUS_PROPOSED, "proposed to node1"); err != nil {
+		t.Fatalf("unexpected error from SetNodePolicyStatus: %v", err)
+	}
+
+	status := pm.GetNodePolicyStatus("myorg", "pol1")
+	if status == nil || status["node1"] == nil {
+		t.Fatalf("expected status for node1 to be set")
+	}
+	if status["node1"].State != STATUS_PROPOSED {
+		t.Errorf("expected state %v, got %v", STATUS_PROPOSED, status["node1"].State)
+	}
+
+	// Mutating the returned snapshot must not affect the PolicyManager's internal state.
+	status["node1"].State = STATUS_FAILED
+	fresh := pm.GetNodePolicyStatus("myorg", "pol1")
+	if fresh["node1"].State != STATUS_PROPOSED {
+		t.Errorf("expected internal state to remain %v, got %v; GetNodePolicyStatus must return a copy", STATUS_PROPOSED, fresh["node1"].State)
+	}
+
+	if err := pm.SetNodePolicyStatus("myorg", "pol1", "node1", STATUS_ACCEPTED, ""); err != nil {
+		t.Fatalf("unexpected error updating status: %v", err)
+	}
+	if got := pm.GetNodePolicyStatus("myorg", "pol1")["node1"].State; got != STATUS_ACCEPTED {
+		t.Errorf("expected state %v after transition, got %v", STATUS_ACCEPTED, got)
+	}
+}
+
+// TestGetNodePolicyStatusUnknownPolicy verifies nil is returned for a business policy this
+// PolicyManager does not know about.
+func TestGetNodePolicyStatusUnknownPolicy(t *testing.T) {
+	pm := &PolicyManager{OrgPolicies: make(map[string]map[string]*BusinessPolicyEntry)}
+	if status := pm.GetNodePolicyStatus("myorg", "pol1"); status != nil {
+		t.Errorf("expected nil status for unknown business policy, got %v", status)
+	}
+}
+
+// TestAgreementWorkerReportsTransitions verifies that AgreementWorker, the producer that feeds
+// SetNodePolicyStatus, correctly drives a node through the propose/accept/cancel and
+// propose/fail lifecycles.
+func TestAgreementWorkerReportsTransitions(t *testing.T) {
+	pm := &PolicyManager{
+		OrgPolicies: map[string]map[string]*BusinessPolicyEntry{
+			"myorg": {
+				"pol1": {Status: make(map[string]*NodeDeploymentStatus)},
+			},
+		},
+	}
+	w := NewAgreementWorker(pm)
+
+	if err := w.ProposeAgreement("myorg", "pol1", "node1"); err != nil {
+		t.Fatalf("unexpected error from ProposeAgreement: %v", err)
+	}
+	if got := pm.GetNodePolicyStatus("myorg", "pol1")["node1"].State; got != STATUS_PROPOSED {
+		t.Errorf("expected state %v after ProposeAgreement, got %v", STATUS_PROPOSED, got)
+	}
+
+	if err := w.AcceptAgreement("myorg", "pol1", "node1"); err != nil {
+		t.Fatalf("unexpected error from AcceptAgreement: %v", err)
+	}
+	if got := pm.GetNodePolicyStatus("myorg", "pol1")["node1"].State; got != STATUS_ACCEPTED {
+		t.Errorf("expected state %v after AcceptAgreement, got %v", STATUS_ACCEPTED, got)
+	}
+
+	if err := w.CancelAgreement("myorg", "pol1", "node1", "node left the org"); err != nil {
+		t.Fatalf("unexpected error from CancelAgreement: %v", err)
+	}
+	status := pm.GetNodePolicyStatus("myorg", "pol1")["node1"]
+	if status.State != STATUS_CANCELLED || status.Message != "node left the org" {
+		t.Errorf("expected state %v with cancellation reason, got %v / %q", STATUS_CANCELLED, status.State, status.Message)
+	}
+
+	if err := w.ProposeAgreement("myorg", "pol1", "node2"); err != nil {
+		t.Fatalf("unexpected error from ProposeAgreement: %v", err)
+	}
+	if err := w.FailAgreement("myorg", "pol1", "node2", "timed out"); err != nil {
+		t.Fatalf("unexpected error from FailAgreement: %v", err)
+	}
+	status2 := pm.GetNodePolicyStatus("myorg", "pol1")["node2"]
+	if status2.State != STATUS_FAILED || status2.Message != "timed out" {
+		t.Errorf("expected state %v with failure reason, got %v / %q", STATUS_FAILED, status2.State, status2.Message)
+	}
+}
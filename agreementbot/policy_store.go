@@ -0,0 +1,177 @@
+package agreementbot
+
+import (
+	"encoding/json"
+	"github.com/boltdb/bolt"
+	"strings"
+	"time"
+)
+
+// PolicyStore persists the BusinessPolicyEntry data that PolicyManager otherwise rebuilds from
+// scratch by re-fetching from the exchange on every agbot restart. A clean restart against an
+// unchanged exchange should load the prior state, compare hashes, and emit no policy change events.
+type PolicyStore interface {
+	// SaveEntry persists (or replaces) the given business policy entry under org/polName.
+	SaveEntry(org string, polName string, pe *BusinessPolicyEntry) error
+
+	// LoadAll returns every persisted business policy entry, keyed the same way as
+	// PolicyManager.OrgPolicies: org, then business policy name.
+	LoadAll() (map[string]map[string]*BusinessPolicyEntry, error)
+
+	// DeleteEntry removes the persisted entry for org/polName, if any.
+	DeleteEntry(org string, polName string) error
+
+	// DeleteOrg removes every persisted entry for org.
+	DeleteOrg(org string) error
+
+	// SaveNotificationDestination persists (or replaces) the given notification destination.
+	SaveNotificationDestination(dest *NotificationDestination) error
+
+	// LoadNotificationDestinations returns every persisted notification destination.
+	LoadNotificationDestinations() ([]*NotificationDestination, error)
+
+	// DeleteNotificationDestination removes the persisted destination registered under org/polNameGlob,
+	// if any.
+	DeleteNotificationDestination(org string, polNameGlob string) error
+}
+
+// policyBucketPrefix namespaces the per-org bolt buckets used by BoltPolicyStore, so that LoadAll can
+// tell them apart from other buckets in the same file, such as notificationDestinationBucketName.
+const policyBucketPrefix = "bp_policies."
+
+func orgBucketName(org string) []byte {
+	return []byte(policyBucketPrefix + org)
+}
+
+// notificationDestinationBucketName is the single bucket that holds every persisted
+// NotificationDestination, keyed by its org/polNameGlob notification key.
+var notificationDestinationBucketName = []byte("bp_notification_destinations")
+
+// BoltPolicyStore is the default PolicyStore implementation, backed by a local boltdb file. Each
+// org gets its own bucket; business policy names are the keys within it.
+type BoltPolicyStore struct {
+	db *bolt.DB
+}
+
+func NewBoltPolicyStore(dbPath string) (*BoltPolicyStore, error) {
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	return &BoltPolicyStore{db: db}, nil
+}
+
+func (s *BoltPolicyStore) SaveEntry(org string, polName string, pe *BusinessPolicyEntry) error {
+	data, err := json.Marshal(pe)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(orgBucketName(org))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(polName), data)
+	})
+}
+
+func (s *BoltPolicyStore) LoadAll() (map[string]map[string]*BusinessPolicyEntry, error) {
+	result := make(map[string]map[string]*BusinessPolicyEntry)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			bucketName := string(name)
+			if !strings.HasPrefix(bucketName, policyBucketPrefix) {
+				return nil
+			}
+			org := strings.TrimPrefix(bucketName, policyBucketPrefix)
+
+			orgPolicies := make(map[string]*BusinessPolicyEntry)
+			err := b.ForEach(func(k, v []byte) error {
+				pe := new(BusinessPolicyEntry)
+				if err := json.Unmarshal(v, pe); err != nil {
+					return err
+				}
+				polName := string(k)
+				pe.polId = org + "/" + polName
+				orgPolicies[polName] = pe
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			result[org] = orgPolicies
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *BoltPolicyStore) DeleteEntry(org string, polName string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(orgBucketName(org))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(polName))
+	})
+}
+
+func (s *BoltPolicyStore) DeleteOrg(org string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(orgBucketName(org)) == nil {
+			return nil
+		}
+		return tx.DeleteBucket(orgBucketName(org))
+	})
+}
+
+func (s *BoltPolicyStore) SaveNotificationDestination(dest *NotificationDestination) error {
+	data, err := json.Marshal(dest)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(notificationDestinationBucketName)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(notificationKey(dest.Org, dest.PolNameGlob)), data)
+	})
+}
+
+func (s *BoltPolicyStore) LoadNotificationDestinations() ([]*NotificationDestination, error) {
+	dests := make([]*NotificationDestination, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(notificationDestinationBucketName)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			dest := new(NotificationDestination)
+			if err := json.Unmarshal(v, dest); err != nil {
+				return err
+			}
+			dests = append(dests, dest)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dests, nil
+}
+
+func (s *BoltPolicyStore) DeleteNotificationDestination(org string, polNameGlob string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(notificationDestinationBucketName)
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(notificationKey(org, polNameGlob)))
+	})
+}
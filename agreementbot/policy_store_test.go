@@ -0,0 +1,161 @@
+package agreementbot
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePolicyStore is an in-memory PolicyStore used to test NewPolicyManager's restore path, and the
+// persist worker's write ordering, without touching disk. lock guards entries/dests since
+// runPersistWorker calls into this store from its own goroutine.
+type fakePolicyStore struct {
+	lock    sync.Mutex
+	entries map[string]map[string]*BusinessPolicyEntry
+	dests   map[string]*NotificationDestination
+}
+
+func newFakePolicyStore() *fakePolicyStore {
+	return &fakePolicyStore{
+		entries: make(map[string]map[string]*BusinessPolicyEntry),
+		dests:   make(map[string]*NotificationDestination),
+	}
+}
+
+func (s *fakePolicyStore) SaveEntry(org string, polName string, pe *BusinessPolicyEntry) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.entries[org]; !ok {
+		s.entries[org] = make(map[string]*BusinessPolicyEntry)
+	}
+	s.entries[org][polName] = pe
+	return nil
+}
+
+func (s *fakePolicyStore) LoadAll() (map[string]map[string]*BusinessPolicyEntry, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for org, orgPolicies := range s.entries {
+		for polName, pe := range orgPolicies {
+			pe.polId = org + "/" + polName
+		}
+	}
+	return s.entries, nil
+}
+
+func (s *fakePolicyStore) DeleteEntry(org string, polName string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.entries[org], polName)
+	return nil
+}
+
+func (s *fakePolicyStore) DeleteOrg(org string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.entries, org)
+	return nil
+}
+
+func (s *fakePolicyStore) SaveNotificationDestination(dest *NotificationDestination) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.dests[notificationKey(dest.Org, dest.PolNameGlob)] = dest
+	return nil
+}
+
+func (s *fakePolicyStore) LoadNotificationDestinations() ([]*NotificationDestination, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	dests := make([]*NotificationDestination, 0, len(s.dests))
+	for _, dest := range s.dests {
+		dests = append(dests, dest)
+	}
+	return dests, nil
+}
+
+func (s *fakePolicyStore) DeleteNotificationDestination(org string, polNameGlob string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.dests, notificationKey(org, polNameGlob))
+	return nil
+}
+
+// entryExists reports whether org/polName is currently present in the store, taking lock so it is
+// safe to call concurrently with runPersistWorker applying queued writes.
+func (s *fakePolicyStore) entryExists(org string, polName string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, ok := s.entries[org][polName]
+	return ok
+}
+
+// TestCleanRestartLoadsPriorStateWithoutEvents verifies that a PolicyManager created against a store
+// that already has persisted entries/destinations loads them directly into OrgPolicies/polIdIndex/
+// NotificationDestinations, and that doing so never touches the (here, nil) event channel -- a clean
+// restart against an unchanged exchange should not, by itself, emit any policy change events.
+func TestCleanRestartLoadsPriorStateWithoutEvents(t *testing.T) {
+	store := newFakePolicyStore()
+	pe := &BusinessPolicyEntry{
+		Updated:         1,
+		Hash:            []byte("h1"),
+		ServicePolicies: make(map[string]*ServicePolicyEntry),
+		Status:          make(map[string]*NodeDeploymentStatus),
+	}
+	if err := store.SaveEntry("myorg", "pol1", pe); err != nil {
+		t.Fatalf("unexpected error seeding fake store: %v", err)
+	}
+	if err := store.SaveNotificationDestination(&NotificationDestination{Org: "myorg", PolNameGlob: "*", Url: "http://example.invalid"}); err != nil {
+		t.Fatalf("unexpected error seeding fake store: %v", err)
+	}
+
+	// No event channel at all: if restoring state tried to emit anything, this would block forever on
+	// a nil channel send instead of returning.
+	pm, err := NewPolicyManager(nil, store)
+	if err != nil {
+		t.Fatalf("unexpected error creating policy manager: %v", err)
+	}
+
+	if !pm.hasBusinessPolicy("myorg", "pol1") {
+		t.Errorf("expected pol1 to be restored into myorg")
+	}
+	if pm.polIdIndex["myorg/pol1"] == nil {
+		t.Errorf("expected polIdIndex to be populated for the restored entry")
+	}
+	if _, ok := pm.NotificationDestinations[notificationKey("myorg", "*")]; !ok {
+		t.Errorf("expected the persisted notification destination to be restored")
+	}
+}
+
+// TestPersistWriteOrderingSurvivesRapidUpdateThenDelete verifies that persistEntry followed by
+// persistDeleteEntry for the same org/polName is always applied to the store in that order, even
+// when the calls happen back to back -- i.e. the delete can never race ahead of the save and leave a
+// stale entry resurrected in the store.
+func TestPersistWriteOrderingSurvivesRapidUpdateThenDelete(t *testing.T) {
+	store := newFakePolicyStore()
+	pm := &PolicyManager{
+		store:        store,
+		persistQueue: newPersistQueue(),
+	}
+	go pm.runPersistWorker()
+
+	for i := 0; i < 50; i++ {
+		pm.persistEntry("myorg", "pol1", &BusinessPolicyEntry{Updated: uint64(i)})
+		pm.persistDeleteEntry("myorg", "pol1")
+	}
+
+	// Give the worker a chance to drain the queue; poll rather than a fixed sleep since the queue has
+	// no notion of "processed", only "no longer pending".
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if pm.persistQueue.empty() {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	if store.entryExists("myorg", "pol1") {
+		t.Errorf("expected pol1 to remain deleted after an update-then-delete burst, but it was resurrected")
+	}
+}
@@ -0,0 +1,73 @@
+package agreementbot
+
+import (
+	"testing"
+)
+
+// TestValidatePropertiesRejectsInvalid verifies that validateProperties rejects properties that do
+// not conform to a registered JSON schema, and is a no-op when no schema is registered.
+func TestValidatePropertiesRejectsInvalid(t *testing.T) {
+	pm2, err := NewPolicyManager(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating policy manager: %v", err)
+	}
+
+	// No schema registered for "svc1": validation is a no-op.
+	if err := pm2.validateProperties("svc1", map[string]string{"color": "green"}); err != nil {
+		t.Errorf("expected no error with no schema registered, got %v", err)
+	}
+
+	schema := []byte(`{
+		"type": "object",
+		"properties": {"color": {"type": "string", "enum": ["red", "green", "blue"]}},
+		"required": ["color"]
+	}`)
+	if err := pm2.RegisterPropertySchema("svc1", schema); err != nil {
+		t.Fatalf("unexpected error registering property schema: %v", err)
+	}
+
+	if err := pm2.validateProperties("svc1", map[string]string{"color": "green"}); err != nil {
+		t.Errorf("expected valid properties to pass schema validation, got %v", err)
+	}
+	if err := pm2.validateProperties("svc1", map[string]string{"color": "purple"}); err == nil {
+		t.Errorf("expected invalid properties to fail schema validation")
+	}
+}
+
+// TestValidatePropertiesCoversConstraints verifies that validateProperties is run against the
+// combined properties/constraints payload policy entries build, so a schema that requires
+// "constraints" rejects a policy that only sets properties, and an invalid constraints value is
+// caught even when properties is valid.
+func TestValidatePropertiesCoversConstraints(t *testing.T) {
+	pm, err := NewPolicyManager(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error creating policy manager: %v", err)
+	}
+
+	schema := []byte(`{
+		"type": "object",
+		"properties": {
+			"properties": {"type": "array"},
+			"constraints": {"type": "array"}
+		},
+		"required": ["properties", "constraints"]
+	}`)
+	if err := pm.RegisterPropertySchema("pol1", schema); err != nil {
+		t.Fatalf("unexpected error registering property schema: %v", err)
+	}
+
+	// Omitting constraints entirely must fail: a nil Constraints marshals to JSON null, which fails
+	// the schema's "type": "array" check for that field.
+	if err := pm.validateProperties("pol1", policyValidationPayload{Properties: []string{"a == b"}, Constraints: nil}); err == nil {
+		t.Errorf("expected validation to fail when constraints is omitted but required by schema")
+	}
+
+	// An invalid constraints value must fail even when properties is valid.
+	if err := pm.validateProperties("pol1", policyValidationPayload{Properties: []string{"a == b"}, Constraints: "not-an-array"}); err == nil {
+		t.Errorf("expected validation to fail for a constraints value that does not match the schema")
+	}
+
+	if err := pm.validateProperties("pol1", policyValidationPayload{Properties: []string{"a == b"}, Constraints: []string{"c == d"}}); err != nil {
+		t.Errorf("expected validation to pass when both properties and constraints satisfy the schema, got %v", err)
+	}
+}
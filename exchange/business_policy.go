@@ -0,0 +1,28 @@
+package exchange
+
+import (
+	"github.com/open-horizon/anax/businesspolicy"
+	"strings"
+)
+
+// ExchangeBusinessPolicy is the exchange representation of a single business policy definition, as
+// returned when listing the business policies defined within an org.
+type ExchangeBusinessPolicy struct {
+	businesspolicy.BusinessPolicy
+	Owner       string `json:"owner,omitempty"`
+	LastUpdated string `json:"lastUpdated,omitempty"`
+}
+
+// GetBusinessPolicy returns the business policy definition embedded in e.
+func (e ExchangeBusinessPolicy) GetBusinessPolicy() businesspolicy.BusinessPolicy {
+	return e.BusinessPolicy
+}
+
+// GetId strips the leading "org/" prefix off a full exchange resource id, returning just the part
+// of the id after the org. It returns id unchanged if id does not contain an org separator.
+func GetId(id string) string {
+	if i := strings.Index(id, "/"); i >= 0 {
+		return id[i+1:]
+	}
+	return id
+}
@@ -0,0 +1,14 @@
+package exchange
+
+// ServedBusinessPolicy is the exchange representation of a policy_org/policy/node_org triplet that
+// an agbot is configured to serve. BusinessPol and BusinessPolOrg may each be an exact name/org, or
+// "*" (BusinessPol only) to mean "every business policy in BusinessPolOrg". BusinessPolPrefix and
+// BusinessPolOrgPrefix are the prefix-matching counterparts: when set, they match any business
+// policy name (or org) that begins with the given prefix, instead of requiring an exact match.
+type ServedBusinessPolicy struct {
+	BusinessPolOrg       string `json:"businessPolOrg"`
+	BusinessPol          string `json:"businessPol"`
+	BusinessPolPrefix    string `json:"businessPolPrefix,omitempty"`
+	BusinessPolOrgPrefix string `json:"businessPolOrgPrefix,omitempty"`
+	NodeOrg              string `json:"nodeOrg"`
+}